@@ -1,46 +1,25 @@
 package discovery
 
 import (
-	"errors"
-	"sync"
+	"context"
 	"time"
 
-	"github.com/agilab/telegraf_api/internal/logger"
+	"github.com/molon/utils/lg"
 
 	"google.golang.org/grpc/naming"
 
-	"golang.org/x/net/context"
-
 	etcd "github.com/coreos/etcd/clientv3"
 	etcdnaming "github.com/coreos/etcd/clientv3/naming"
 )
 
-const closeContextTimeout = 5 * time.Second
-
-//ErrDiscoveryServiceKeepAliveInvalidCall KeepAlive不能被调用两次
-var ErrDiscoveryServiceKeepAliveInvalidCall = errors.New("discovery: KeepAlive cant be called twice for one Service")
-
-//ErrDiscoveryServiceClosed Service is closed
-var ErrDiscoveryServiceClosed = errors.New("discovery: Service is closed")
-
 //Service etcd discoverer
 type Service struct {
-	mu sync.Mutex
-
-	ctx    context.Context
-	cancel context.CancelFunc
-
 	service string
 	address *Address
 	ttl     int64
-	logger  *logger.Logger
-
-	client         *etcd.Client
-	leaseGrantResp *etcd.LeaseGrantResponse
-	resolver       *etcdnaming.GRPCResolver
+	logger  lg.Logger
 
-	keeping bool
-	done    bool
+	client *etcd.Client
 }
 
 //Address service addr and metadata
@@ -50,202 +29,110 @@ type Address struct {
 }
 
 //NewService new service for registering into etcd
-func NewService(client *etcd.Client, service string, address *Address, ttl int64, logger *logger.Logger) *Service {
+func NewService(client *etcd.Client, service string, address *Address, ttl int64, logger lg.Logger) *Service {
 	if ttl < 3 {
 		ttl = 3
 	}
-	d := &Service{
+	return &Service{
 		client:  client,
 		service: service,
 		address: address,
 		ttl:     ttl,
-		logger:  logger,
+		logger:  logger.With("service", service, "addr", address.Addr),
 	}
-	d.ctx, d.cancel = context.WithCancel(client.Ctx())
-	return d
 }
 
-//Close close
-func (d *Service) Close() error {
-	//取消当前所有etcd的rpc请求
-	d.cancel()
-
-	//此时再拿锁，KeepAlive会因为上述的cancel将锁解除
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if d.done {
-		return ErrDiscoveryServiceClosed
-	}
-	d.done = true
-
-	if d.resolver != nil {
-		ctx, cancel := context.WithTimeout(d.resolver.Client.Ctx(), time.Duration(d.ttl)*time.Second)
-		if err := d.resolver.Update(ctx,
-			d.service,
-			naming.Update{
-				Op:   naming.Delete,
-				Addr: d.address.Addr}); err != nil && err != context.Canceled {
-			d.logger.Errorf("[discovery] delete service error - %s", err)
-		} else {
-			d.logger.Infof("[discovery] unregister service(%s) from etcd", d.service+"/"+d.address.Addr)
-		}
-		cancel()
-		d.resolver = nil
-	}
-
-	if d.client != nil {
-		if d.leaseGrantResp != nil {
-			ctx, cancel := context.WithTimeout(d.client.Ctx(), time.Duration(d.ttl)*time.Second)
-			if _, err := d.client.Revoke(ctx, d.leaseGrantResp.ID); err != nil && err != context.Canceled {
-				d.logger.Errorf("[discovery] revoke lease error - %s", err)
-			}
-			cancel()
-			d.leaseGrantResp = nil
-		}
-	}
-
-	return nil
+//Name returns the service name this Service registers under, so a
+//supervisor can log service lifecycles uniformly.
+func (d *Service) Name() string {
+	return d.service
 }
 
-func (d *Service) register() error {
-	cli := d.client
-
-	ctx, _ := context.WithCancel(d.ctx)
-	resp, err := cli.Grant(ctx, d.ttl)
+func (d *Service) register(ctx context.Context) (*etcdnaming.GRPCResolver, *etcd.LeaseGrantResponse, error) {
+	resp, err := d.client.Grant(ctx, d.ttl)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	d.leaseGrantResp = resp
-
-	r := &etcdnaming.GRPCResolver{Client: cli}
-	d.resolver = r
 
-	opts := etcd.WithLease(resp.ID)
-	ctx, _ = context.WithCancel(d.ctx)
+	r := &etcdnaming.GRPCResolver{Client: d.client}
 	if err := r.Update(ctx,
 		d.service,
 		naming.Update{
 			Op:       naming.Add,
 			Addr:     d.address.Addr,
 			Metadata: d.address.Metadata},
-		opts); err != nil {
-		return err
+		etcd.WithLease(resp.ID)); err != nil {
+		return nil, nil, err
 	}
 
-	d.logger.Infof("[discovery] register service(%s) into etcd", d.service+"/"+d.address.Addr)
+	d.logger.Info("discovery: register service into etcd")
 
-	return nil
+	return r, resp, nil
 }
 
-//KeepAlive keepalive
-func (d *Service) KeepAlive() error {
-	d.mu.Lock()
-	if d.done {
-		d.mu.Unlock()
-		return ErrDiscoveryServiceClosed
-	}
+//deregister removes the registration from etcd and revokes its lease,
+//using ctx rather than whatever context Run was cancelled by, since that
+//one is already dead by the time this runs.
+func (d *Service) deregister(r *etcdnaming.GRPCResolver, lease *etcd.LeaseGrantResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.ttl)*time.Second)
+	defer cancel()
 
-	if d.keeping || d.client == nil {
-		d.mu.Unlock()
-		return ErrDiscoveryServiceKeepAliveInvalidCall
+	if r != nil {
+		if err := r.Update(ctx, d.service, naming.Update{Op: naming.Delete, Addr: d.address.Addr}); err != nil {
+			d.logger.Error("discovery: delete service error", "err", err)
+		} else {
+			d.logger.Info("discovery: unregister service from etcd")
+		}
 	}
 
-	defer func() {
-		d.keeping = false
-	}()
-	d.keeping = true
+	if lease != nil {
+		if _, err := d.client.Revoke(ctx, lease.ID); err != nil {
+			d.logger.Error("discovery: revoke lease error", "err", err)
+		}
+	}
+}
 
-	//KeepAlive 实现
-	err := d.register()
+//Run registers the service into etcd and keeps its lease alive until ctx
+//is cancelled or a fatal error occurs, at which point it returns.
+//Once the initial registration has succeeded, deregistration always runs
+//before Run returns, even on error.
+func (d *Service) Run(ctx context.Context) error {
+	r, lease, err := d.register(ctx)
 	if err != nil {
-		d.mu.Unlock()
 		return err
 	}
+	defer func() {
+		d.deregister(r, lease)
+	}()
 
-	ctx, _ := context.WithCancel(d.ctx)
-	ch, err := d.client.KeepAlive(ctx, d.leaseGrantResp.ID)
+	ch, err := d.client.KeepAlive(ctx, lease.ID)
 	if err != nil {
-		d.mu.Unlock()
 		return err
 	}
 
-	d.mu.Unlock()
-
 	for {
 		select {
-		case <-d.ctx.Done():
-			return d.ctx.Err()
-		case resp := <-ch:
-			if resp == nil {
-				d.mu.Lock()
-
-				if d.done {
-					d.mu.Unlock()
-					return ErrDiscoveryServiceClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-ch:
+			if !ok || resp == nil {
+				// KeepAlive closes ch once ctx is cancelled; that is
+				// shutdown, not a lost lease.
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
-
-				err = d.register()
+				r, lease, err = d.register(ctx)
 				if err != nil {
-					d.mu.Unlock()
 					return err
 				}
 
-				ctx, _ = context.WithCancel(d.ctx)
-				ch, err = d.client.KeepAlive(ctx, d.leaseGrantResp.ID)
+				ch, err = d.client.KeepAlive(ctx, lease.ID)
 				if err != nil {
-					d.mu.Unlock()
 					return err
 				}
-				d.mu.Unlock()
+				continue
 			}
-			d.logger.Debugf("[discovery] service keepalive")
+			d.logger.Debug("discovery: service keepalive")
 		}
 	}
-
-	//KeepAliveOnce 实现
-
-	// err = d.register()
-	// if err != nil {
-	// 	d.mu.Unlock()
-	// 	return err
-	// }
-
-	// d.mu.Unlock()
-
-	// ticker := time.NewTicker(time.Duration(d.ttl/3) * time.Second)
-	// defer ticker.Stop()
-
-	// for {
-	// 	select {
-	// 	case <-d.ctx.Done():
-	// 		return d.ctx.Err()
-	// 	case <-ticker.C:
-	// 		var err error
-
-	// 		d.mu.Lock()
-
-	//if d.done {
-	//	d.mu.Unlock()
-	//	return ErrDiscoveryClosed
-	//}
-	// 		if d.leaseGrantResp != nil {
-	// 			ctx, _ = context.WithCancel(d.ctx)
-	// 			_, err = d.client.KeepAliveOnce(ctx, d.leaseGrantResp.ID)
-	// 			if err == nil {
-	// 				d.logger.Debugf("[discovery] service keepalive %v", d.leaseGrantResp.ID)
-	// 			} else if err == rpctypes.ErrLeaseNotFound {
-	// 				err = d.register()
-	// 			}
-	// 		} else {
-	// 			err = d.register()
-	// 		}
-
-	// 		d.mu.Unlock()
-
-	// 		if err != nil {
-	// 			return err
-	// 		}
-	// 	}
-	// }
 }
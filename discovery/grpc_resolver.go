@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"context"
+
+	"github.com/molon/utils/lg"
+
+	etcd "github.com/coreos/etcd/clientv3"
+
+	"google.golang.org/grpc/resolver"
+)
+
+//EtcdScheme is the scheme registered by NewGRPCResolverBuilder, letting
+//gRPC clients dial "etcd:///my-service".
+const EtcdScheme = "etcd"
+
+//grpcResolverBuilder adapts Resolver to grpc/resolver.Builder, replacing
+//the deprecated grpc/naming.Resolver used previously.
+type grpcResolverBuilder struct {
+	client *etcd.Client
+	logger lg.Logger
+}
+
+//NewGRPCResolverBuilder returns a resolver.Builder backed by etcd. Register
+//it once via resolver.Register so grpc.Dial("etcd:///my-service", ...)
+//tracks the live, lease-tracked address set. It supplies addresses only,
+//no service config: how calls are spread over them is up to the client's
+//load-balancing policy (gRPC's default, pick_first, uses one at a time).
+func NewGRPCResolverBuilder(client *etcd.Client, logger lg.Logger) resolver.Builder {
+	return &grpcResolverBuilder{client: client, logger: logger}
+}
+
+func (b *grpcResolverBuilder) Scheme() string { return EtcdScheme }
+
+func (b *grpcResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	service := target.Endpoint
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := NewResolver(b.client, b.logger)
+	ch, err := r.Resolve(ctx, service)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	gr := &grpcResolver{cancel: cancel}
+	go gr.run(cc, ch)
+	return gr, nil
+}
+
+//grpcResolver implements resolver.Resolver by forwarding Resolver's address
+//channel into the grpc.ClientConn's state.
+type grpcResolver struct {
+	cancel context.CancelFunc
+}
+
+func (r *grpcResolver) run(cc resolver.ClientConn, ch <-chan []Address) {
+	for addrs := range ch {
+		state := resolver.State{Addresses: make([]resolver.Address, 0, len(addrs))}
+		for _, a := range addrs {
+			state.Addresses = append(state.Addresses, resolver.Address{Addr: a.Addr})
+		}
+		cc.UpdateState(state)
+	}
+}
+
+func (r *grpcResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *grpcResolver) Close() { r.cancel() }
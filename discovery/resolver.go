@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/molon/utils/lg"
+
+	etcd "github.com/coreos/etcd/clientv3"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+//ResolverOption configures a Resolver returned by NewResolver.
+type ResolverOption func(*Resolver)
+
+//WithMetadataFilter only keeps addresses whose metadata passes filter.
+func WithMetadataFilter(filter func(metadata string) bool) ResolverOption {
+	return func(r *Resolver) { r.metadataFilter = filter }
+}
+
+//WithBackoff overrides the reconnect backoff bounds used after a watch is
+//disrupted (default 100ms..30s).
+func WithBackoff(min, max time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.minBackoff = min
+		r.maxBackoff = max
+	}
+}
+
+//Resolver watches etcd for registrations made by Service and maintains a
+//live Address set per service name. It is the client-side counterpart to
+//Service, which only handles registration.
+type Resolver struct {
+	client *etcd.Client
+	logger lg.Logger
+
+	metadataFilter func(string) bool
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+
+	mu        sync.RWMutex
+	endpoints map[string][]Address
+}
+
+//NewResolver creates a Resolver backed by client.
+func NewResolver(client *etcd.Client, logger lg.Logger, opts ...ResolverOption) *Resolver {
+	r := &Resolver{
+		client:     client,
+		logger:     logger,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		endpoints:  make(map[string][]Address),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+//Endpoints returns a snapshot of the addresses currently known for
+//service. It never blocks on etcd; it is populated once Resolve has
+//returned for service.
+func (r *Resolver) Endpoints(service string) []Address {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Address, len(r.endpoints[service]))
+	copy(out, r.endpoints[service])
+	return out
+}
+
+//Resolve watches the etcd prefix service is registered under and returns a
+//channel emitting the full current Address set every time it changes. The
+//channel is closed once ctx is cancelled. Disconnects are retried with
+//exponential backoff.
+func (r *Resolver) Resolve(ctx context.Context, service string) (<-chan []Address, error) {
+	prefix := servicePrefix(service)
+
+	initial, err := r.client.Get(ctx, prefix, etcd.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make(map[string]Address, len(initial.Kvs))
+	for _, kv := range initial.Kvs {
+		if a, ok := decodeAddress(kv.Value, r.metadataFilter); ok {
+			addrs[string(kv.Key)] = a
+		}
+	}
+
+	out := make(chan []Address, 1)
+	r.publish(service, addrs, out)
+
+	go r.watch(ctx, service, prefix, addrs, out, initial.Header.Revision+1)
+
+	return out, nil
+}
+
+func (r *Resolver) watch(ctx context.Context, service, prefix string, addrs map[string]Address, out chan []Address, rev int64) {
+	defer close(out)
+
+	backoff := r.minBackoff
+	for {
+		r.watchOnce(ctx, service, prefix, addrs, out, &rev)
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		r.logger.Warn("discovery: watch disconnected, retrying", "service", service, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
+
+//watchOnce runs a single etcd watch until it errors out or ctx is
+//cancelled, applying deduped events to addrs, advancing *rev and
+//publishing the full set to out after every response that changed addrs.
+func (r *Resolver) watchOnce(ctx context.Context, service, prefix string, addrs map[string]Address, out chan []Address, rev *int64) {
+	wch := r.client.Watch(ctx, prefix, etcd.WithPrefix(), etcd.WithRev(*rev))
+	for resp := range wch {
+		if err := resp.Err(); err != nil {
+			r.logger.Error("discovery: watch error", "service", service, "err", err)
+			return
+		}
+
+		changed := false
+		for _, ev := range resp.Events {
+			key := string(ev.Kv.Key)
+			switch ev.Type {
+			case etcd.EventTypeDelete:
+				if _, ok := addrs[key]; ok {
+					delete(addrs, key)
+					changed = true
+				}
+			default:
+				a, ok := decodeAddress(ev.Kv.Value, r.metadataFilter)
+				if !ok {
+					if _, exists := addrs[key]; exists {
+						delete(addrs, key)
+						changed = true
+					}
+					continue
+				}
+				if old, exists := addrs[key]; !exists || old != a {
+					addrs[key] = a
+					changed = true
+				}
+			}
+		}
+
+		*rev = resp.Header.Revision + 1
+		if changed {
+			r.publish(service, addrs, out)
+		}
+	}
+}
+
+func (r *Resolver) publish(service string, addrs map[string]Address, out chan []Address) {
+	list := make([]Address, 0, len(addrs))
+	for _, a := range addrs {
+		list = append(list, a)
+	}
+
+	r.mu.Lock()
+	r.endpoints[service] = list
+	r.mu.Unlock()
+
+	snapshot := make([]Address, len(list))
+	copy(snapshot, list)
+
+	select {
+	case out <- snapshot:
+	default:
+		// drop the stale pending value so a slow consumer never blocks us
+		select {
+		case <-out:
+		default:
+		}
+		out <- snapshot
+	}
+}
+
+//servicePrefix must match the key scheme etcdnaming.GRPCResolver.Update
+//uses in Service.register: service + "/" + addr, with no leading slash.
+func servicePrefix(service string) string {
+	return service + "/"
+}
+
+//registrationValue mirrors the JSON etcdnaming.GRPCResolver.Update stores
+//for each registered address.
+type registrationValue struct {
+	Addr     string `json:"Addr"`
+	Metadata string `json:"Metadata,omitempty"`
+}
+
+func decodeAddress(value []byte, filter func(string) bool) (Address, bool) {
+	var v registrationValue
+	if err := json.Unmarshal(value, &v); err != nil {
+		return Address{}, false
+	}
+	if filter != nil && !filter(v.Metadata) {
+		return Address{}, false
+	}
+	return Address{Addr: v.Addr, Metadata: v.Metadata}, true
+}
@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/naming"
+)
+
+//TestServicePrefixMatchesRegisterKeyScheme guards against the prefix used
+//by Resolver.Resolve/watch drifting from the key scheme
+//etcdnaming.GRPCResolver.Update actually writes in Service.register
+//(service + "/" + addr, no leading slash). If these diverge, a Resolver
+//never sees anything registered by this package's own Service.
+func TestServicePrefixMatchesRegisterKeyScheme(t *testing.T) {
+	const service = "myservice"
+	const addr = "1.2.3.4:8080"
+
+	key := service + "/" + addr // what etcdnaming.GRPCResolver.Update stores
+
+	if !strings.HasPrefix(key, servicePrefix(service)) {
+		t.Fatalf("servicePrefix(%q) = %q does not match register key %q", service, servicePrefix(service), key)
+	}
+}
+
+//TestDecodeAddressMatchesRegistrationValue checks decodeAddress against the
+//JSON value shape etcdnaming.GRPCResolver.Update writes for a
+//naming.Update{Addr, Metadata}.
+func TestDecodeAddressMatchesRegistrationValue(t *testing.T) {
+	// etcdnaming.GRPCResolver.Update stores json.Marshal of the naming.Update.
+	value, err := json.Marshal(naming.Update{Op: naming.Add, Addr: "1.2.3.4:8080", Metadata: "v1"})
+	if err != nil {
+		t.Fatalf("Marshal: %s\n", err)
+	}
+
+	a, ok := decodeAddress(value, nil)
+	if !ok {
+		t.Fatalf("decodeAddress: expected ok, got false for %s\n", value)
+	}
+	if a.Addr != "1.2.3.4:8080" || a.Metadata != "v1" {
+		t.Fatalf("decodeAddress: unexpected Address %+v\n", a)
+	}
+}
@@ -0,0 +1,164 @@
+package http_api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/molon/utils/lg"
+)
+
+// statusWriter wraps an http.ResponseWriter to capture the status code and
+// byte count written. Handlers are not given it on its own when the
+// underlying writer has optional interfaces: newStatusWriter
+// picks a concrete wrapper type that implements exactly the optional
+// interfaces (http.Flusher, http.Hijacker, http.CloseNotifier) the
+// underlying ResponseWriter implements, the way httpsnoop's CaptureMetrics
+// does, so callers doing SSE flushing or WebSocket hijacking through a type
+// assertion keep working.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+type swFlusher struct{ *statusWriter }
+
+func (w swFlusher) Flush() { w.ResponseWriter.(http.Flusher).Flush() }
+
+type swHijacker struct{ *statusWriter }
+
+func (w swHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type swCloseNotifier struct{ *statusWriter }
+
+func (w swCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type swFlusherHijacker struct{ *statusWriter }
+
+func (w swFlusherHijacker) Flush() { w.ResponseWriter.(http.Flusher).Flush() }
+func (w swFlusherHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type swFlusherCloseNotifier struct{ *statusWriter }
+
+func (w swFlusherCloseNotifier) Flush() { w.ResponseWriter.(http.Flusher).Flush() }
+func (w swFlusherCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type swHijackerCloseNotifier struct{ *statusWriter }
+
+func (w swHijackerCloseNotifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+func (w swHijackerCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type swFlusherHijackerCloseNotifier struct{ *statusWriter }
+
+func (w swFlusherHijackerCloseNotifier) Flush() { w.ResponseWriter.(http.Flusher).Flush() }
+func (w swFlusherHijackerCloseNotifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+func (w swFlusherHijackerCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// newStatusWriter wraps w in a statusWriter, returning a concrete type that
+// implements exactly the optional interfaces w itself implements.
+func newStatusWriter(w http.ResponseWriter) (http.ResponseWriter, *statusWriter) {
+	sw := &statusWriter{ResponseWriter: w}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isCloseNotifier := w.(http.CloseNotifier)
+
+	switch {
+	case isFlusher && isHijacker && isCloseNotifier:
+		return swFlusherHijackerCloseNotifier{sw}, sw
+	case isFlusher && isHijacker:
+		return swFlusherHijacker{sw}, sw
+	case isFlusher && isCloseNotifier:
+		return swFlusherCloseNotifier{sw}, sw
+	case isHijacker && isCloseNotifier:
+		return swHijackerCloseNotifier{sw}, sw
+	case isFlusher:
+		return swFlusher{sw}, sw
+	case isHijacker:
+		return swHijacker{sw}, sw
+	case isCloseNotifier:
+		return swCloseNotifier{sw}, sw
+	default:
+		return sw, sw
+	}
+}
+
+// defaultAccessLogDebugStatus downgrades only 2xx responses to DEBUG; see
+// WithAccessLogDebugStatus.
+func defaultAccessLogDebugStatus(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// accessLogMiddleware logs one line per request at INFO (DEBUG if
+// debugStatus(status) is true), attaching method, path, status, bytes
+// written, latency and client IP as structured fields via logger.With.
+func accessLogMiddleware(next http.Handler, logger lg.Logger, debugStatus func(int) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrapped, sw := newStatusWriter(w)
+
+		next.ServeHTTP(wrapped, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		l := logger.With(
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+			"client", clientIP(r),
+		)
+		if debugStatus(status) {
+			l.Debug("HTTP request")
+		} else {
+			l.Info("HTTP request")
+		}
+	})
+}
+
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,35 +1,163 @@
 package http_api
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/molon/utils/lg"
 )
 
 type logWriter struct {
-	logf lg.AppLogFunc
+	logger lg.Logger
 }
 
 func (l logWriter) Write(p []byte) (int, error) {
-	l.logf(lg.WARN, "%s", string(p))
+	l.logger.Warn(strings.TrimRight(string(p), "\n"))
 	return len(p), nil
 }
 
-func Serve(listener net.Listener, handler http.Handler, logf lg.AppLogFunc) {
-	logf(lg.INFO, "HTTP: listening on %s", listener.Addr())
+// Serve is a convenience wrapper around ServeWithContext for callers that
+// don't need graceful shutdown: it serves until listener is closed.
+func Serve(listener net.Listener, handler http.Handler, logger lg.Logger, opts ...Option) {
+	ServeWithContext(context.Background(), listener, handler, logger, opts...)
+}
+
+// ServeWithContext serves handler on listener until ctx is cancelled, at
+// which point it calls server.Shutdown with a drain timeout (see
+// WithShutdownTimeout). By default it also wraps handler with an access-log
+// middleware and registers /healthz and /metrics endpoints; see the Option
+// functions to change that. logger is tagged with the listener's addr via
+// With() so every line it emits carries that context as a structured field
+// instead of a format string.
+func ServeWithContext(ctx context.Context, listener net.Listener, handler http.Handler, logger lg.Logger, opts ...Option) error {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	logger = logger.With("addr", listener.Addr())
+
+	mux := http.NewServeMux()
+	if o.healthzPath != "" {
+		mux.HandleFunc(o.healthzPath, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	if o.metricsHandler != nil {
+		mux.Handle(o.metricsPath, o.metricsHandler)
+	}
+	mux.Handle("/", handler)
+
+	var h http.Handler = mux
+	if o.accessLog {
+		h = accessLogMiddleware(h, logger, o.accessLogDebugStatus)
+	}
 
 	server := &http.Server{
-		Handler:  handler,
-		ErrorLog: log.New(logWriter{logf}, "", 0),
+		Handler:           h,
+		ErrorLog:          log.New(logWriter{logger}, "", 0),
+		ReadHeaderTimeout: o.readHeaderTimeout,
+		IdleTimeout:       o.idleTimeout,
 	}
-	err := server.Serve(listener)
-	// theres no direct way to detect this error because it is not exposed
-	if err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
-		logf(lg.ERROR, "http.Serve() - %s", err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("HTTP: listening")
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("HTTP: shutdown error", "err", err)
+		}
+		<-errCh
+		logger.Info("HTTP: closed")
+		return ctx.Err()
+	case err := <-errCh:
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("http.Serve() error", "err", err)
+			return err
+		}
+		logger.Info("HTTP: closing")
+		return nil
 	}
+}
+
+// Option configures ServeWithContext.
+type Option func(*options)
+
+type options struct {
+	readHeaderTimeout time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+
+	healthzPath string
+
+	metricsPath    string
+	metricsHandler http.Handler
+
+	accessLog            bool
+	accessLogDebugStatus func(int) bool
+}
+
+func defaultOptions() *options {
+	return &options{
+		readHeaderTimeout:    10 * time.Second,
+		idleTimeout:          120 * time.Second,
+		shutdownTimeout:      10 * time.Second,
+		healthzPath:          "/healthz",
+		metricsPath:          "/metrics",
+		metricsHandler:       expvar.Handler(),
+		accessLog:            true,
+		accessLogDebugStatus: defaultAccessLogDebugStatus,
+	}
+}
+
+// WithReadHeaderTimeout sets http.Server.ReadHeaderTimeout (default 10s).
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(o *options) { o.readHeaderTimeout = d }
+}
+
+// WithIdleTimeout sets http.Server.IdleTimeout (default 120s).
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) { o.idleTimeout = d }
+}
+
+// WithShutdownTimeout bounds how long ServeWithContext waits for
+// in-flight requests to drain after ctx is cancelled (default 10s).
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) { o.shutdownTimeout = d }
+}
+
+// WithHealthz changes the path the built-in health check is served on, or
+// disables it entirely when path is "".
+func WithHealthz(path string) Option {
+	return func(o *options) { o.healthzPath = path }
+}
+
+// WithMetrics changes the path and handler the metrics endpoint is served
+// on, or disables it entirely when handler is nil. The default handler is
+// expvar.Handler() served at /metrics.
+func WithMetrics(path string, handler http.Handler) Option {
+	return func(o *options) { o.metricsPath = path; o.metricsHandler = handler }
+}
+
+// WithAccessLog enables or disables the access-log middleware (on by
+// default).
+func WithAccessLog(enabled bool) Option {
+	return func(o *options) { o.accessLog = enabled }
+}
 
-	logf(lg.INFO, "HTTP: closing %s", listener.Addr())
+// WithAccessLogDebugStatus changes which response statuses the access-log
+// middleware logs at DEBUG instead of INFO (default: 2xx only).
+func WithAccessLogDebugStatus(debugStatus func(status int) bool) Option {
+	return func(o *options) { o.accessLogDebugStatus = debugStatus }
 }
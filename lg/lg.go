@@ -2,9 +2,11 @@ package lg
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // LogLevel log level
@@ -28,17 +30,66 @@ type Logger interface {
 	Warnf(format string, v ...interface{})
 	Errorf(format string, v ...interface{})
 	Fatalf(format string, v ...interface{})
+
+	// Enabled reports whether a log site at lvl would actually be emitted,
+	// so callers can skip assembling expensive arguments (JSON marshaling,
+	// hex-encoding large buffers, ...) when it wouldn't be.
+	Enabled(lvl LogLevel) bool
+
+	// Level returns the logger's current minimum level.
+	Level() LogLevel
+
+	// SetLevel atomically changes the minimum level, e.g. so an operator
+	// can flip a running server from INFO to DEBUG without a restart. It
+	// affects this Logger and every Logger derived from it via With().
+	SetLevel(lvl LogLevel)
+
+	// With returns a child Logger that carries keyvals as structured fields
+	// on every subsequent call, in addition to any fields already attached
+	// to this Logger.
+	With(keyvals ...interface{}) Logger
+
+	// Debug, Info, Warn, Error and Fatal log msg together with the
+	// structured fields carried by With() plus keyvals, which must be an
+	// alternating list of key, value.
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+	Fatal(msg string, keyvals ...interface{})
+}
+
+// Entry is a single log record handed to a Sink.
+type Entry struct {
+	Level  LogLevel
+	Time   time.Time
+	Msg    string
+	Fields []interface{}
+}
+
+// levelString returns the lowercase name used in structured output, e.g. the
+// JSON "lvl" field.
+func levelString(lvl LogLevel) string {
+	switch lvl {
+	case DEBUG:
+		return "debug"
+	case INFO:
+		return "info"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	case FATAL:
+		return "fatal"
+	}
+	return "unknown"
 }
 
 type logger struct {
-	goLogger   *log.Logger
-	logLevel   LogLevel
-	exitFunc   func()
-	debugLabel string
-	infoLabel  string
-	warnLabel  string
-	errorLabel string
-	fatalLabel string
+	level    *int32 // atomic, shared with every Logger derived via With()
+	exitFunc func()
+	fields   []interface{}
+	sinks    []Sink
 }
 
 // Generate the pid prefix string
@@ -46,57 +97,76 @@ func pidPrefix() string {
 	return fmt.Sprintf("[%d] ", os.Getpid())
 }
 
-func setLabelFormats(l *logger, colors bool) {
-	if colors {
-		colorFormat := "[\x1b[%dm%s\x1b[0m] "
-		l.debugLabel = fmt.Sprintf(colorFormat, 36, "DBG")
-		l.infoLabel = fmt.Sprintf(colorFormat, 32, "INF")
-		l.warnLabel = fmt.Sprintf(colorFormat, 33, "WRN")
-		l.errorLabel = fmt.Sprintf(colorFormat, 31, "ERR")
-		l.fatalLabel = fmt.Sprintf(colorFormat, 35, "FTL")
-	} else {
-		l.debugLabel = "[DBG] "
-		l.infoLabel = "[INF] "
-		l.warnLabel = "[WRN] "
-		l.errorLabel = "[ERR] "
-		l.fatalLabel = "[FTL] "
-	}
-}
-
-// NewStdLogger creates a logger with output directed to Stderr
-func NewStdLogger(prefix string, time, colors, pid bool, logLevel LogLevel, exitFunc func()) Logger {
-	flags := 0
-	if time {
-		flags = log.LstdFlags | log.Lmicroseconds
-	}
-
+// NewStdLogger creates a logger that writes to Stderr through a StderrSink.
+// The sink accepts every level, so the logger's own level (see SetLevel)
+// alone decides what is written.
+func NewStdLogger(prefix string, withTime, colors, pid bool, logLevel LogLevel, exitFunc func()) Logger {
 	pre := prefix
 	if pid {
 		pre += pidPrefix()
 	}
+	return newLogger(logLevel, exitFunc, NewStderrSink(pre, withTime, colors, DEBUG))
+}
+
+// NewJSONLogger creates a logger that writes one JSON object per line to w,
+// with keys in sorted order, e.g.
+// {"hash":"abcd1234","lvl":"info","msg":"...","ts":1690000000}.
+// The sink accepts every level, so the logger's own level alone decides
+// what is written.
+func NewJSONLogger(w io.Writer, logLevel LogLevel, exitFunc func()) Logger {
+	return newLogger(logLevel, exitFunc, NewJSONSink(w, DEBUG))
+}
 
-	l := &logger{
-		goLogger: log.New(os.Stderr, pre, flags),
-		logLevel: logLevel,
+// newLogger builds a Logger that fans every entry out to sinks, gated by a
+// shared top-level level check; each sink additionally filters by its own
+// min-level.
+func newLogger(logLevel LogLevel, exitFunc func(), sinks ...Sink) *logger {
+	lvl := int32(logLevel)
+	return &logger{
+		level:    &lvl,
 		exitFunc: exitFunc,
+		sinks:    sinks,
 	}
-
-	setLabelFormats(l, colors)
-
-	return l
 }
 
-func NewCommonStdLoggerWithLevelStr(prefix, levelStr string, exitFunc func()) (Logger, error) {
+// NewCommonStdLoggerWithLevelStr builds a Logger. With no sinkSpecs it
+// behaves as before, logging to stderr only at levelStr. With sinkSpecs,
+// levelStr is only validated: the Logger's level is the lowest level of
+// its sinks, and each sink filters by its own level. Each sinkSpec is a
+// colon-separated "kind:level[:...]" or "kind:path:level[:opt=value...]"
+// string, e.g. "stderr:info" or "file:/var/log/app.log:debug:rotate=100MB",
+// letting CLIs take a single repeatable --log flag and fan out to several
+// sinks at once, each at its own level (see parseSinkSpec).
+func NewCommonStdLoggerWithLevelStr(prefix, levelStr string, exitFunc func(), sinkSpecs ...string) (Logger, error) {
 	logLevel, err := ParseLogLevel(levelStr)
 	if err != nil {
 		return nil, err
 	}
-	colors := true
-	stat, err := os.Stderr.Stat()
-	if err != nil || (stat.Mode()&os.ModeCharDevice) == 0 {
-		colors = false
+
+	if len(sinkSpecs) == 0 {
+		return NewStdLogger(prefix, true, stderrIsTerminal(), (prefix == ""), logLevel, exitFunc), nil
 	}
-	return NewStdLogger(prefix, true, colors, (prefix == ""), logLevel, exitFunc), nil
+
+	sinks := make([]Sink, 0, len(sinkSpecs))
+	gate := FATAL
+	for _, spec := range sinkSpecs {
+		sink, err := parseSinkSpec(spec, prefix)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+		if lvl := sink.Level(); lvl < gate {
+			gate = lvl
+		}
+	}
+	// The shared gate must let through anything any sink wants, or sinks
+	// configured below levelStr would never see their own entries.
+	return newLogger(gate, exitFunc, sinks...), nil
+}
+
+func stderrIsTerminal() bool {
+	stat, err := os.Stderr.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) != 0
 }
 
 // ParseLogLevel parse a levvel str to LogLevel
@@ -121,29 +191,47 @@ func ParseLogLevel(levelstr string) (LogLevel, error) {
 	return lvl, nil
 }
 
-// Logf printf log with level and format
-func (l *logger) Logf(msgLevel LogLevel, format string, v ...interface{}) {
-	if l.logLevel > msgLevel {
-		return
+// log renders msg plus the logger's attached fields and keyvals into an
+// Entry and fans it out to every sink. Callers are expected to have already
+// checked Enabled.
+func (l *logger) log(lvl LogLevel, msg string, keyvals []interface{}) {
+	fields := keyvals
+	if len(l.fields) > 0 {
+		fields = make([]interface{}, 0, len(l.fields)+len(keyvals))
+		fields = append(fields, l.fields...)
+		fields = append(fields, keyvals...)
 	}
 
-	label := ""
-
-	switch msgLevel {
-	case DEBUG:
-		label = l.debugLabel
-	case INFO:
-		label = l.infoLabel
-	case WARN:
-		label = l.warnLabel
-	case ERROR:
-		label = l.errorLabel
-	case FATAL:
-		label = l.fatalLabel
+	e := Entry{Level: lvl, Time: time.Now(), Msg: msg, Fields: fields}
+	for _, sink := range l.sinks {
+		if err := sink.Write(e); err != nil {
+			fmt.Fprintf(os.Stderr, "lg: sink write error: %s\n", err)
+		}
 	}
+}
+
+// Level returns the logger's current minimum level.
+func (l *logger) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(l.level))
+}
 
-	l.goLogger.Printf(label+format, v...)
-	// fmt.Printf(label+format+"\n", v...)
+// SetLevel atomically changes the minimum level for l and every Logger
+// derived from it via With().
+func (l *logger) SetLevel(lvl LogLevel) {
+	atomic.StoreInt32(l.level, int32(lvl))
+}
+
+// Enabled reports whether a log site at lvl would actually be emitted.
+func (l *logger) Enabled(lvl LogLevel) bool {
+	return l.Level() <= lvl
+}
+
+// Logf printf log with level and format
+func (l *logger) Logf(msgLevel LogLevel, format string, v ...interface{}) {
+	if !l.Enabled(msgLevel) {
+		return
+	}
+	l.log(msgLevel, fmt.Sprintf(format, v...), nil)
 }
 
 // Debugf logs a debug statement
@@ -175,3 +263,50 @@ func (l *logger) Fatalf(format string, v ...interface{}) {
 	}
 	os.Exit(1)
 }
+
+// With returns a child Logger carrying keyvals as structured fields on top
+// of any fields l already carries.
+func (l *logger) With(keyvals ...interface{}) Logger {
+	nl := *l
+	nl.fields = make([]interface{}, 0, len(l.fields)+len(keyvals))
+	nl.fields = append(nl.fields, l.fields...)
+	nl.fields = append(nl.fields, keyvals...)
+	return &nl
+}
+
+func (l *logger) Debug(msg string, keyvals ...interface{}) {
+	if !l.Enabled(DEBUG) {
+		return
+	}
+	l.log(DEBUG, msg, keyvals)
+}
+
+func (l *logger) Info(msg string, keyvals ...interface{}) {
+	if !l.Enabled(INFO) {
+		return
+	}
+	l.log(INFO, msg, keyvals)
+}
+
+func (l *logger) Warn(msg string, keyvals ...interface{}) {
+	if !l.Enabled(WARN) {
+		return
+	}
+	l.log(WARN, msg, keyvals)
+}
+
+func (l *logger) Error(msg string, keyvals ...interface{}) {
+	if !l.Enabled(ERROR) {
+		return
+	}
+	l.log(ERROR, msg, keyvals)
+}
+
+func (l *logger) Fatal(msg string, keyvals ...interface{}) {
+	l.log(FATAL, msg, keyvals)
+	if l.exitFunc != nil {
+		l.exitFunc()
+		return
+	}
+	os.Exit(1)
+}
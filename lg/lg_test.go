@@ -2,6 +2,7 @@ package lg
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"log"
 	"os"
@@ -13,13 +14,13 @@ func TestStdLogger(t *testing.T) {
 		os.Exit(1)
 	})
 
-	flags := l.(*logger).goLogger.Flags()
+	flags := l.(*logger).sinks[0].(*StderrSink).goLogger.Flags()
 	if flags != 0 {
 		t.Fatalf("Expected %q, received %q\n", 0, flags)
 	}
 
-	if l.(*logger).logLevel != INFO {
-		t.Fatalf("Expected %d, received %d\n", INFO, l.(*logger).logLevel)
+	if l.Level() != INFO {
+		t.Fatalf("Expected %d, received %d\n", INFO, l.Level())
 	}
 }
 
@@ -28,7 +29,7 @@ func TestStdLoggerWithTime(t *testing.T) {
 		os.Exit(1)
 	})
 
-	flags := l.(*logger).goLogger.Flags()
+	flags := l.(*logger).sinks[0].(*StderrSink).goLogger.Flags()
 	if flags != log.LstdFlags|log.Lmicroseconds {
 		t.Fatalf("Expected %d, received %d\n", log.LstdFlags, flags)
 	}
@@ -70,6 +71,112 @@ func TestStdLoggerDebugWithINFO(t *testing.T) {
 	}, "")
 }
 
+func TestStdLoggerWithFields(t *testing.T) {
+	expectOutput(t, func() {
+		l := NewStdLogger("", false, false, false, INFO, func() {
+			os.Exit(1)
+		})
+		l.With("addr", ":8080").Info("listening", "service", "api")
+	}, "[INF] listening addr=:8080 service=api\n")
+}
+
+func TestStdLoggerSetLevel(t *testing.T) {
+	l := NewStdLogger("", false, false, false, INFO, func() {
+		os.Exit(1)
+	})
+
+	if l.Enabled(DEBUG) {
+		t.Fatalf("expected DEBUG to be disabled at INFO level\n")
+	}
+
+	child := l.With("k", "v")
+	child.SetLevel(DEBUG)
+
+	if !l.Enabled(DEBUG) {
+		t.Fatalf("expected SetLevel on a child logger to affect the parent\n")
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/app.log"
+
+	sink, err := NewFileSink(path, DEBUG, 10, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink: %s\n", err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(Entry{Level: INFO, Msg: "this line is over ten bytes"}); err != nil {
+		t.Fatalf("Write: %s\n", err)
+	}
+	if err := sink.Write(Entry{Level: INFO, Msg: "rotated"}); err != nil {
+		t.Fatalf("Write: %s\n", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected %s to exist after rotation: %s\n", path, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %s\n", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("expected a rotated file alongside %s, got %v\n", path, entries)
+	}
+}
+
+func TestCommonStdLoggerPerSinkLevel(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/app.log"
+
+	l, err := NewCommonStdLoggerWithLevelStr("", "info", nil, "file:"+path+":debug")
+	if err != nil {
+		t.Fatalf("NewCommonStdLoggerWithLevelStr: %s\n", err)
+	}
+	l.Debug("hello")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %s\n", err)
+	}
+	if !bytes.Contains(data, []byte("hello")) {
+		t.Fatalf("expected a DEBUG entry to reach a sink configured below the top-level %q level, got %q\n", "info", data)
+	}
+}
+
+func TestParseSinkSpecDocumentedFileSpec(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/app.log"
+
+	sink, err := parseSinkSpec("file:"+path+":debug:rotate=100MB", "")
+	if err != nil {
+		t.Fatalf("parseSinkSpec: %s\n", err)
+	}
+	defer sink.Close()
+
+	if sink.Level() != DEBUG {
+		t.Fatalf("expected level DEBUG, got %d\n", sink.Level())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, INFO, func() {
+		os.Exit(1)
+	})
+	l.With("hash", "abcd1234").Info("mined block")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected valid JSON line, got %q: %s\n", buf.String(), err)
+	}
+	if entry["msg"] != "mined block" || entry["hash"] != "abcd1234" || entry["lvl"] != "info" {
+		t.Fatalf("unexpected JSON entry: %v\n", entry)
+	}
+}
+
 func expectOutput(t *testing.T, f func(), expected string) {
 	old := os.Stderr // keep backup of the real stdout
 	r, w, _ := os.Pipe()
@@ -0,0 +1,121 @@
+package lg
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Sink is one output backend a Logger fans entries out to. Sinks own their
+// own minimum level, so e.g. DEBUG can go to a file while WARN+ still hits
+// stderr.
+type Sink interface {
+	Write(e Entry) error
+	Close() error
+
+	// Level returns this sink's own minimum level.
+	Level() LogLevel
+	// SetLevel atomically changes this sink's own minimum level.
+	SetLevel(lvl LogLevel)
+}
+
+// levelFilter is embedded by sinks to give each one its own atomically
+// adjustable minimum level.
+type levelFilter struct {
+	level int32 // atomic
+}
+
+func newLevelFilter(logLevel LogLevel) levelFilter {
+	return levelFilter{level: int32(logLevel)}
+}
+
+func (f *levelFilter) enabled(lvl LogLevel) bool {
+	return f.Level() <= lvl
+}
+
+// Level returns this sink's own minimum level.
+func (f *levelFilter) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&f.level))
+}
+
+// SetLevel atomically changes this sink's own minimum level.
+func (f *levelFilter) SetLevel(lvl LogLevel) {
+	atomic.StoreInt32(&f.level, int32(lvl))
+}
+
+func renderText(e Entry, colors bool) string {
+	var b strings.Builder
+	b.WriteString(levelLabel(e.Level, colors))
+	b.WriteString(e.Msg)
+	for i := 0; i+1 < len(e.Fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", e.Fields[i], e.Fields[i+1])
+	}
+	return b.String()
+}
+
+func levelLabel(lvl LogLevel, colors bool) string {
+	if colors {
+		colorFormat := "[\x1b[%dm%s\x1b[0m] "
+		switch lvl {
+		case DEBUG:
+			return fmt.Sprintf(colorFormat, 36, "DBG")
+		case INFO:
+			return fmt.Sprintf(colorFormat, 32, "INF")
+		case WARN:
+			return fmt.Sprintf(colorFormat, 33, "WRN")
+		case ERROR:
+			return fmt.Sprintf(colorFormat, 31, "ERR")
+		case FATAL:
+			return fmt.Sprintf(colorFormat, 35, "FTL")
+		}
+		return ""
+	}
+
+	switch lvl {
+	case DEBUG:
+		return "[DBG] "
+	case INFO:
+		return "[INF] "
+	case WARN:
+		return "[WRN] "
+	case ERROR:
+		return "[ERR] "
+	case FATAL:
+		return "[FTL] "
+	}
+	return ""
+}
+
+// StderrSink writes colorized, human-readable lines to os.Stderr. It backs
+// NewStdLogger.
+type StderrSink struct {
+	levelFilter
+	goLogger *log.Logger
+	colors   bool
+}
+
+// NewStderrSink creates a Sink writing to os.Stderr with prefix and, if
+// withTime, Go's standard date/time flags.
+func NewStderrSink(prefix string, withTime, colors bool, logLevel LogLevel) *StderrSink {
+	flags := 0
+	if withTime {
+		flags = log.LstdFlags | log.Lmicroseconds
+	}
+	return &StderrSink{
+		levelFilter: newLevelFilter(logLevel),
+		goLogger:    log.New(os.Stderr, prefix, flags),
+		colors:      colors,
+	}
+}
+
+func (s *StderrSink) Write(e Entry) error {
+	if !s.enabled(e.Level) {
+		return nil
+	}
+	s.goLogger.Print(renderText(e, s.colors))
+	return nil
+}
+
+func (s *StderrSink) Close() error { return nil }
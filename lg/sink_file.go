@@ -0,0 +1,136 @@
+package lg
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSink writes human-readable lines to a file, rotating it by size
+// and/or age and gzip-compressing the rotated-out file.
+type FileSink struct {
+	levelFilter
+	mu sync.Mutex
+
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileSink opens (or creates) path for appending. maxBytes and maxAge are
+// the size/time rotation thresholds; zero disables that trigger.
+func NewFileSink(path string, logLevel LogLevel, maxBytes int64, maxAge time.Duration) (*FileSink, error) {
+	s := &FileSink{
+		levelFilter: newLevelFilter(logLevel),
+		path:        path,
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = stat.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *FileSink) Write(e Entry) error {
+	if !s.enabled(e.Level) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rotateIfNeeded(); err != nil {
+		return err
+	}
+
+	line := append([]byte(renderText(e, false)), '\n')
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) rotateIfNeeded() error {
+	needsRotate := (s.maxBytes > 0 && s.size >= s.maxBytes) ||
+		(s.maxAge > 0 && time.Since(s.openedAt) >= s.maxAge)
+	if !needsRotate {
+		return nil
+	}
+	return s.rotate()
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := fmt.Sprintf("%s.%s", s.path, time.Now().Format("20060102T150405.000000000"))
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := gzipAndRemove(rotated); err != nil {
+			fmt.Fprintf(os.Stderr, "lg: failed to gzip rotated log %s: %s\n", rotated, err)
+		}
+	}()
+
+	return s.open()
+}
+
+func gzipAndRemove(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		dst.Close()
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,61 @@
+package lg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSONSink writes one JSON object per line to w, with keys in sorted order,
+// e.g. {"hash":"abcd1234","lvl":"info","msg":"...","ts":1690000000}. It backs
+// NewJSONLogger.
+type JSONSink struct {
+	levelFilter
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink creates a Sink that writes JSON lines to w.
+func NewJSONSink(w io.Writer, logLevel LogLevel) *JSONSink {
+	return &JSONSink{
+		levelFilter: newLevelFilter(logLevel),
+		w:           w,
+	}
+}
+
+func (s *JSONSink) Write(e Entry) error {
+	if !s.enabled(e.Level) {
+		return nil
+	}
+
+	m := make(map[string]interface{}, len(e.Fields)/2+3)
+	m["lvl"] = levelString(e.Level)
+	m["ts"] = e.Time.Unix()
+	m["msg"] = e.Msg
+	for i := 0; i+1 < len(e.Fields); i += 2 {
+		key, ok := e.Fields[i].(string)
+		if !ok {
+			key = fmt.Sprintf("%v", e.Fields[i])
+		}
+		m[key] = e.Fields[i+1]
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *JSONSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
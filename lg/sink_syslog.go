@@ -0,0 +1,49 @@
+//go:build !windows
+
+package lg
+
+import "log/syslog"
+
+// SyslogSink writes entries to the local syslog daemon. It is unavailable
+// on windows.
+type SyslogSink struct {
+	levelFilter
+	w *syslog.Writer
+}
+
+// NewSyslogSink dials the local syslog daemon, tagging every message with
+// tag.
+func NewSyslogSink(tag string, logLevel LogLevel) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{
+		levelFilter: newLevelFilter(logLevel),
+		w:           w,
+	}, nil
+}
+
+func (s *SyslogSink) Write(e Entry) error {
+	if !s.enabled(e.Level) {
+		return nil
+	}
+
+	msg := renderText(e, false)
+	switch e.Level {
+	case DEBUG:
+		return s.w.Debug(msg)
+	case WARN:
+		return s.w.Warning(msg)
+	case ERROR:
+		return s.w.Err(msg)
+	case FATAL:
+		return s.w.Crit(msg)
+	default:
+		return s.w.Info(msg)
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
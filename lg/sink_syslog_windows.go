@@ -0,0 +1,18 @@
+//go:build windows
+
+package lg
+
+import "errors"
+
+// SyslogSink is unavailable on windows; NewSyslogSink always errors.
+type SyslogSink struct {
+	levelFilter
+}
+
+// NewSyslogSink returns an error on windows, which has no syslog daemon.
+func NewSyslogSink(tag string, logLevel LogLevel) (*SyslogSink, error) {
+	return nil, errors.New("lg: syslog sink is not supported on windows")
+}
+
+func (s *SyslogSink) Write(e Entry) error { return nil }
+func (s *SyslogSink) Close() error        { return nil }
@@ -0,0 +1,106 @@
+package lg
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseSinkSpec parses one colon-separated sink spec, as used by
+// NewCommonStdLoggerWithLevelStr. Sinks that take a path are
+// "kind:path:level[:opt=value...]", e.g. "file:/var/log/app.log:debug:rotate=100MB";
+// sinks that don't are "kind:level", e.g. "stderr:info", with syslog also
+// taking an optional tag, "syslog:level[:tag]".
+func parseSinkSpec(spec, prefix string) (Sink, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("lg: invalid sink spec %q: want kind:level[:...] or kind:path:level[:...]", spec)
+	}
+
+	kind := parts[0]
+
+	switch kind {
+	case "stderr":
+		lvl, err := ParseLogLevel(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: %w", spec, err)
+		}
+		return NewStderrSink(prefix, true, stderrIsTerminal(), lvl), nil
+
+	case "file":
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: want file:path:level[:opt=value...]", spec)
+		}
+		lvl, err := ParseLogLevel(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: %w", spec, err)
+		}
+		var maxBytes int64
+		for _, opt := range parts[3:] {
+			k, v, ok := strings.Cut(opt, "=")
+			if !ok || k != "rotate" {
+				return nil, fmt.Errorf("lg: invalid sink spec %q: unknown option %q", spec, opt)
+			}
+			maxBytes, err = parseByteSize(v)
+			if err != nil {
+				return nil, fmt.Errorf("lg: invalid sink spec %q: %w", spec, err)
+			}
+		}
+		return NewFileSink(parts[1], lvl, maxBytes, 0)
+
+	case "json":
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: want json:path:level", spec)
+		}
+		lvl, err := ParseLogLevel(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: %w", spec, err)
+		}
+		f, err := os.OpenFile(parts[1], os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewJSONSink(f, lvl), nil
+
+	case "syslog":
+		lvl, err := ParseLogLevel(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("lg: invalid sink spec %q: %w", spec, err)
+		}
+		tag := prefix
+		if len(parts) >= 3 {
+			tag = parts[2]
+		}
+		return NewSyslogSink(tag, lvl)
+
+	default:
+		return nil, fmt.Errorf("lg: invalid sink spec %q: unknown sink kind %q", spec, kind)
+	}
+}
+
+// parseByteSize parses sizes like "100MB", "512KB" or a bare byte count.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "GB"):
+		mult = 1 << 30
+		s = strings.TrimSuffix(s, "GB")
+	case strings.HasSuffix(s, "MB"):
+		mult = 1 << 20
+		s = strings.TrimSuffix(s, "MB")
+	case strings.HasSuffix(s, "KB"):
+		mult = 1 << 10
+		s = strings.TrimSuffix(s, "KB")
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return n * mult, nil
+}